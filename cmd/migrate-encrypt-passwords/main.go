@@ -0,0 +1,128 @@
+// Command migrate-encrypt-passwords is a one-shot maintenance command
+// that wraps every password_hash in the users table with
+// data.EncryptedPasswordCodec, so the application can start requiring
+// PasswordEncryptionKey on a database that predates it.
+//
+// It is idempotent: once every row has been encrypted it records a
+// PasswordsEncryptedKey marker in the properties table and subsequent
+// runs exit immediately without touching the users table again.
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"log"
+	"os"
+
+	"greenlight.bcc/internal/data"
+
+	_ "github.com/lib/pq"
+)
+
+const propertiesMarkerKey = "PasswordsEncryptedKey"
+
+func main() {
+	var dsn string
+	var encryptionKeys string
+
+	flag.StringVar(&dsn, "db-dsn", os.Getenv("GREENLIGHT_DB_DSN"), "PostgreSQL DSN")
+	flag.StringVar(&encryptionKeys, "encryption-keys", os.Getenv("PASSWORD_ENCRYPTION_KEY"), "comma-separated PasswordEncryptionKey list, first key used for writes")
+	flag.Parse()
+
+	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+
+	codec, err := data.NewEncryptedPasswordCodec(encryptionKeys)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	if err := run(db, codec, logger); err != nil {
+		logger.Fatal(err)
+	}
+}
+
+func run(db *sql.DB, codec *data.EncryptedPasswordCodec, logger *log.Logger) error {
+	if err := ensurePropertiesTable(db); err != nil {
+		return err
+	}
+
+	already, err := alreadyEncrypted(db)
+	if err != nil {
+		return err
+	}
+	if already {
+		logger.Println("passwords already encrypted, nothing to do")
+		return nil
+	}
+
+	rows, err := db.Query(`SELECT id, password_hash FROM users`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type pendingRow struct {
+		id   int64
+		hash []byte
+	}
+	var pending []pendingRow
+
+	for rows.Next() {
+		var row pendingRow
+		if err := rows.Scan(&row.id, &row.hash); err != nil {
+			return err
+		}
+		if _, err := codec.Decrypt(row.hash); err == nil {
+			continue // already wrapped, leave it alone
+		}
+		pending = append(pending, row)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, row := range pending {
+		ciphertext, err := codec.Encrypt(row.hash)
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec(`UPDATE users SET password_hash = $1 WHERE id = $2`, ciphertext, row.id); err != nil {
+			return err
+		}
+	}
+
+	logger.Printf("encrypted %d password hash(es)", len(pending))
+
+	_, err = db.Exec(`
+	INSERT INTO properties (key, value) VALUES ($1, 'true')
+	ON CONFLICT (key) DO NOTHING`, propertiesMarkerKey)
+	return err
+}
+
+func ensurePropertiesTable(db *sql.DB) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS properties (
+		key   text PRIMARY KEY,
+		value text NOT NULL
+	)`)
+	return err
+}
+
+func alreadyEncrypted(db *sql.DB) (bool, error) {
+	var value string
+	err := db.QueryRow(`SELECT value FROM properties WHERE key = $1`, propertiesMarkerKey).Scan(&value)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	default:
+		return true, nil
+	}
+}
@@ -0,0 +1,39 @@
+// Package dto holds the wire-format request/response shapes for users,
+// kept separate from data.User so that HTTP input is never bound
+// directly onto the internal model.
+package dto
+
+import "time"
+
+// UserCreateDTO is the request body accepted when registering a new
+// user.
+type UserCreateDTO struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// UserExistDTO is the request body accepted on login.
+type UserExistDTO struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// UserUpdateDTO is the request body accepted when updating a user.
+// Fields are pointers so a field that's omitted from the request can be
+// told apart from one explicitly cleared.
+type UserUpdateDTO struct {
+	Name     *string `json:"name"`
+	Email    *string `json:"email"`
+	Password *string `json:"password"`
+}
+
+// UserResponseDTO is the shape returned to API clients. It never
+// carries the password hash.
+type UserResponseDTO struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Activated bool      `json:"activated"`
+}
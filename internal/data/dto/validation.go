@@ -0,0 +1,46 @@
+package dto
+
+import "greenlight.bcc/internal/validator"
+
+func ValidateEmail(v *validator.Validator, email string) {
+	v.Check(email != "", "email", "must be provided")
+	v.Check(validator.Matches(email, validator.EmailRX), "email", "must be a valid email address")
+}
+
+func ValidatePasswordPlaintext(v *validator.Validator, password string) {
+	v.Check(password != "", "password", "must be provided")
+	v.Check(len(password) >= 8, "password", "must be at least 8 bytes long")
+	v.Check(len(password) <= 72, "password", "must not be more than 72 bytes long")
+}
+
+func validateName(v *validator.Validator, name string) {
+	v.Check(name != "", "name", "must be provided")
+	v.Check(len(name) <= 500, "name", "must not be more than 500 bytes long")
+}
+
+// ValidateUserCreate validates a registration request.
+func ValidateUserCreate(v *validator.Validator, d *UserCreateDTO) {
+	validateName(v, d.Name)
+	ValidateEmail(v, d.Email)
+	ValidatePasswordPlaintext(v, d.Password)
+}
+
+// ValidateUserExist validates a login request.
+func ValidateUserExist(v *validator.Validator, d *UserExistDTO) {
+	ValidateEmail(v, d.Email)
+	ValidatePasswordPlaintext(v, d.Password)
+}
+
+// ValidateUserUpdate validates an update request, only checking fields
+// that were actually supplied.
+func ValidateUserUpdate(v *validator.Validator, d *UserUpdateDTO) {
+	if d.Name != nil {
+		validateName(v, *d.Name)
+	}
+	if d.Email != nil {
+		ValidateEmail(v, *d.Email)
+	}
+	if d.Password != nil {
+		ValidatePasswordPlaintext(v, *d.Password)
+	}
+}
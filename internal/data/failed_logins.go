@@ -0,0 +1,49 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// AuthRateLimitConfig controls the sliding-window failed-login limiter
+// enforced by UserModel.Authenticate, loaded from the AuthRequestLimit
+// / AuthWindowLength application config options.
+type AuthRateLimitConfig struct {
+	RequestLimit int
+	WindowLength time.Duration
+}
+
+// FailedLoginModel records failed login attempts in the failed_logins
+// table, so Authenticate can enforce a sliding-window rate limit per
+// (email, ip) pair and clear it again once a login succeeds.
+type FailedLoginModel struct {
+	DB *sql.DB
+}
+
+// Count returns how many failed attempts have been recorded for email
+// and ip within the last window.
+func (m FailedLoginModel) Count(ctx context.Context, email, ip string, window time.Duration) (int, error) {
+	query := `
+	SELECT count(*) FROM failed_logins
+	WHERE email = $1 AND ip = $2 AND attempted_at > $3`
+
+	var count int
+	err := m.DB.QueryRowContext(ctx, query, email, ip, time.Now().Add(-window)).Scan(&count)
+	return count, err
+}
+
+// Record inserts a failed attempt for email and ip.
+func (m FailedLoginModel) Record(ctx context.Context, email, ip string) error {
+	query := `INSERT INTO failed_logins (email, ip, attempted_at) VALUES ($1, $2, $3)`
+	_, err := m.DB.ExecContext(ctx, query, email, ip, time.Now())
+	return err
+}
+
+// Clear removes every recorded failed attempt for email and ip,
+// called after a successful login.
+func (m FailedLoginModel) Clear(ctx context.Context, email, ip string) error {
+	query := `DELETE FROM failed_logins WHERE email = $1 AND ip = $2`
+	_, err := m.DB.ExecContext(ctx, query, email, ip)
+	return err
+}
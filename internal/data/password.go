@@ -0,0 +1,192 @@
+package data
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnknownPasswordAlgorithm is returned when a stored password hash
+// doesn't carry a recognised algorithm identifier.
+var ErrUnknownPasswordAlgorithm = errors.New("data: unknown password hash algorithm")
+
+const argon2idPrefix = "$argon2id$"
+
+// PasswordHasher is implemented by every supported password hashing
+// scheme. The returned hash must encode enough information (algorithm
+// identifier, cost/parameters, salt) for a later Matches call - possibly
+// against a different active hasher - to verify it.
+type PasswordHasher interface {
+	Hash(plaintext string) ([]byte, error)
+	Matches(hash []byte, plaintext string) (bool, error)
+	// NeedsRehash reports whether hash was produced with weaker
+	// parameters than this hasher is currently configured with, and
+	// should therefore be upgraded on next successful login.
+	NeedsRehash(hash []byte) bool
+}
+
+// BcryptHasher is the original password hashing scheme used by this
+// application.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(plaintext string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(plaintext), h.Cost)
+}
+
+func (h BcryptHasher) Matches(hash []byte, plaintext string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword(hash, []byte(plaintext))
+	if err != nil {
+		switch {
+		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (h BcryptHasher) NeedsRehash(hash []byte) bool {
+	cost, err := bcrypt.Cost(hash)
+	if err != nil {
+		return true
+	}
+	return cost < h.Cost
+}
+
+// Argon2idParams holds the tunable cost parameters for the Argon2id
+// hasher, loaded from application config.
+type Argon2idParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Argon2idHasher implements PasswordHasher on top of golang.org/x/crypto/argon2.
+type Argon2idHasher struct {
+	Params Argon2idParams
+}
+
+func (h Argon2idHasher) Hash(plaintext string) ([]byte, error) {
+	salt := make([]byte, h.Params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(plaintext), salt, h.Params.Iterations, h.Params.Memory, h.Params.Parallelism, h.Params.KeyLength)
+
+	encoded := fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.Params.Memory,
+		h.Params.Iterations,
+		h.Params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+
+	return []byte(encoded), nil
+}
+
+func (h Argon2idHasher) Matches(hash []byte, plaintext string) (bool, error) {
+	params, salt, key, err := decodeArgon2idHash(hash)
+	if err != nil {
+		return false, err
+	}
+
+	otherKey := argon2.IDKey([]byte(plaintext), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	if subtle.ConstantTimeCompare(key, otherKey) == 1 {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (h Argon2idHasher) NeedsRehash(hash []byte) bool {
+	params, _, _, err := decodeArgon2idHash(hash)
+	if err != nil {
+		return true
+	}
+	return params.Memory < h.Params.Memory ||
+		params.Iterations < h.Params.Iterations ||
+		params.Parallelism < h.Params.Parallelism
+}
+
+func decodeArgon2idHash(hash []byte) (Argon2idParams, []byte, []byte, error) {
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 {
+		return Argon2idParams{}, nil, nil, fmt.Errorf("data: invalid argon2id hash format")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return Argon2idParams{}, nil, nil, err
+	}
+	if version != argon2.Version {
+		return Argon2idParams{}, nil, nil, fmt.Errorf("data: incompatible argon2id version %d", version)
+	}
+
+	var params Argon2idParams
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2idParams{}, nil, nil, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2idParams{}, nil, nil, err
+	}
+	params.SaltLength = uint32(len(salt))
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2idParams{}, nil, nil, err
+	}
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
+
+// hasherForHash picks the verifier matching the algorithm identifier
+// encoded in hash, so that Matches keeps working for old hashes while
+// the active hasher (used for new hashes and rehashing) moves on.
+func hasherForHash(hash []byte) (PasswordHasher, error) {
+	switch {
+	case strings.HasPrefix(string(hash), argon2idPrefix):
+		return Argon2idHasher{Params: Argon2idParams{Memory: 64 * 1024, Iterations: 1, Parallelism: 2, SaltLength: 16, KeyLength: 32}}, nil
+	case strings.HasPrefix(string(hash), "$2a$"), strings.HasPrefix(string(hash), "$2b$"), strings.HasPrefix(string(hash), "$2y$"):
+		return BcryptHasher{Cost: bcrypt.DefaultCost}, nil
+	default:
+		return nil, ErrUnknownPasswordAlgorithm
+	}
+}
+
+// PasswordHasherConfig mirrors the password-hashing section of the
+// application config and is used to build the active PasswordHasher at
+// startup.
+type PasswordHasherConfig struct {
+	Algorithm  string // "bcrypt" or "argon2id"
+	BcryptCost int
+	Argon2     Argon2idParams
+}
+
+// NewPasswordHasher builds the PasswordHasher that UserModel should use
+// for hashing new passwords and deciding whether existing ones need
+// rehashing, based on the active algorithm in cfg.
+func NewPasswordHasher(cfg PasswordHasherConfig) PasswordHasher {
+	switch cfg.Algorithm {
+	case "argon2id":
+		return Argon2idHasher{Params: cfg.Argon2}
+	default:
+		return BcryptHasher{Cost: cfg.BcryptCost}
+	}
+}
@@ -0,0 +1,138 @@
+package data
+
+import "testing"
+
+func TestBcryptHasherHashAndMatches(t *testing.T) {
+	h := BcryptHasher{Cost: 10}
+
+	hash, err := h.Hash("pa55word")
+	if err != nil {
+		t.Fatalf("Hash returned error: %v", err)
+	}
+
+	matches, err := h.Matches(hash, "pa55word")
+	if err != nil {
+		t.Fatalf("Matches returned error: %v", err)
+	}
+	if !matches {
+		t.Error("expected matching password to match")
+	}
+
+	matches, err = h.Matches(hash, "wrong-password")
+	if err != nil {
+		t.Fatalf("Matches returned error: %v", err)
+	}
+	if matches {
+		t.Error("expected wrong password not to match")
+	}
+}
+
+func TestBcryptHasherNeedsRehash(t *testing.T) {
+	low := BcryptHasher{Cost: 4}
+	hash, err := low.Hash("pa55word")
+	if err != nil {
+		t.Fatalf("Hash returned error: %v", err)
+	}
+
+	if low.NeedsRehash(hash) {
+		t.Error("hash at the active cost should not need rehashing")
+	}
+
+	higher := BcryptHasher{Cost: 10}
+	if !higher.NeedsRehash(hash) {
+		t.Error("hash below the active cost should need rehashing")
+	}
+}
+
+func TestArgon2idHasherHashAndMatches(t *testing.T) {
+	h := Argon2idHasher{Params: Argon2idParams{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}}
+
+	hash, err := h.Hash("pa55word")
+	if err != nil {
+		t.Fatalf("Hash returned error: %v", err)
+	}
+
+	matches, err := h.Matches(hash, "pa55word")
+	if err != nil {
+		t.Fatalf("Matches returned error: %v", err)
+	}
+	if !matches {
+		t.Error("expected matching password to match")
+	}
+
+	matches, err = h.Matches(hash, "wrong-password")
+	if err != nil {
+		t.Fatalf("Matches returned error: %v", err)
+	}
+	if matches {
+		t.Error("expected wrong password not to match")
+	}
+}
+
+func TestArgon2idHasherNeedsRehash(t *testing.T) {
+	weak := Argon2idHasher{Params: Argon2idParams{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}}
+	hash, err := weak.Hash("pa55word")
+	if err != nil {
+		t.Fatalf("Hash returned error: %v", err)
+	}
+
+	if weak.NeedsRehash(hash) {
+		t.Error("hash at the active params should not need rehashing")
+	}
+
+	stronger := Argon2idHasher{Params: Argon2idParams{Memory: 64 * 1024, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 32}}
+	if !stronger.NeedsRehash(hash) {
+		t.Error("hash below the active params should need rehashing")
+	}
+}
+
+func TestPasswordMatchesDispatchesByAlgorithm(t *testing.T) {
+	bcryptHash, err := BcryptHasher{Cost: 10}.Hash("pa55word")
+	if err != nil {
+		t.Fatalf("Hash returned error: %v", err)
+	}
+	argonHash, err := (Argon2idHasher{Params: Argon2idParams{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}}).Hash("pa55word")
+	if err != nil {
+		t.Fatalf("Hash returned error: %v", err)
+	}
+
+	for name, hash := range map[string][]byte{"bcrypt": bcryptHash, "argon2id": argonHash} {
+		p := password{hash: hash}
+
+		matches, err := p.Matches("pa55word")
+		if err != nil {
+			t.Fatalf("%s: Matches returned error: %v", name, err)
+		}
+		if !matches {
+			t.Errorf("%s: expected matching password to match", name)
+		}
+
+		matches, err = p.Matches("wrong-password")
+		if err != nil {
+			t.Fatalf("%s: Matches returned error: %v", name, err)
+		}
+		if matches {
+			t.Errorf("%s: expected wrong password not to match", name)
+		}
+	}
+}
+
+func TestPasswordMatchesUnknownAlgorithm(t *testing.T) {
+	p := password{hash: []byte("not-a-recognised-hash-format")}
+
+	if _, err := p.Matches("pa55word"); err != ErrUnknownPasswordAlgorithm {
+		t.Errorf("expected ErrUnknownPasswordAlgorithm, got %v", err)
+	}
+}
+
+func TestNewPasswordHasher(t *testing.T) {
+	bcryptHasher := NewPasswordHasher(PasswordHasherConfig{Algorithm: "bcrypt", BcryptCost: 11})
+	if _, ok := bcryptHasher.(BcryptHasher); !ok {
+		t.Errorf("expected BcryptHasher for algorithm %q, got %T", "bcrypt", bcryptHasher)
+	}
+
+	argon2Hasher := NewPasswordHasher(PasswordHasherConfig{Algorithm: "argon2id", Argon2: Argon2idParams{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}})
+	if _, ok := argon2Hasher.(Argon2idHasher); !ok {
+		t.Errorf("expected Argon2idHasher for algorithm %q, got %T", "argon2id", argon2Hasher)
+	}
+}
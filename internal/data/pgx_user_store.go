@@ -0,0 +1,195 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"greenlight.bcc/internal/data/sqlc"
+)
+
+// pgxErrCodeDuplicateEmail is the Postgres SQLSTATE for a unique
+// violation, returned by the users_email_key constraint.
+const pgxErrCodeDuplicateEmail = "23505"
+
+// pgxUserStore is the jackc/pgx/v5 + sqlc-generated-query counterpart
+// to UserModel, built by NewUserStore("pgx", ...). It satisfies the
+// same UserStore interface, so handlers and tests can't tell which
+// driver they're talking to.
+type pgxUserStore struct {
+	Pool    *pgxpool.Pool
+	Queries *sqlc.Queries
+	Hasher  PasswordHasher
+	// Codec and FailedLogins/AuthLimit mirror UserModel's - see the
+	// doc comments there.
+	Codec        *EncryptedPasswordCodec
+	FailedLogins FailedLoginStore
+	AuthLimit    AuthRateLimitConfig
+}
+
+// NewPgxUserStore wires pool, the active password hasher and sqlc
+// queries generated from internal/data/queries into a pgxUserStore,
+// applying any opts on top. opts reuses UserModelOption since both
+// backends expose the same Codec/FailedLogins/AuthLimit knobs.
+func NewPgxUserStore(pool *pgxpool.Pool, hasher PasswordHasher, opts ...UserModelOption) *pgxUserStore {
+	m := UserModel{Hasher: hasher}
+	for _, opt := range opts {
+		opt(&m)
+	}
+	return &pgxUserStore{
+		Pool:         pool,
+		Queries:      sqlc.New(pool),
+		Hasher:       m.Hasher,
+		Codec:        m.Codec,
+		FailedLogins: m.FailedLogins,
+		AuthLimit:    m.AuthLimit,
+	}
+}
+
+func (s pgxUserStore) Authenticate(ctx context.Context, email, plaintextPassword, ip string) (*User, error) {
+	return authenticate(ctx, email, plaintextPassword, ip, s.GetByEmail, s.Update, s.Hasher, s.FailedLogins, s.AuthLimit)
+}
+
+func (s pgxUserStore) Insert(user *User) error {
+	passwordHash := user.Password.hash
+	if s.Codec != nil {
+		encrypted, err := s.Codec.Encrypt(passwordHash)
+		if err != nil {
+			return err
+		}
+		passwordHash = encrypted
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	row, err := s.Queries.InsertUser(ctx, sqlc.InsertUserParams{
+		Name:         user.Name,
+		Email:        user.Email,
+		PasswordHash: passwordHash,
+		Activated:    user.Activated,
+	})
+	if err != nil {
+		if isPgxDuplicateEmail(err) {
+			return ErrDuplicateEmail
+		}
+		return err
+	}
+
+	user.ID = row.ID
+	user.CreatedAt = row.CreatedAt
+	user.Version = int(row.Version)
+	return nil
+}
+
+func (s pgxUserStore) GetByEmail(email string) (*User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	row, err := s.Queries.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	user := sqlcUserToUser(row)
+
+	if s.Codec != nil {
+		user.Password.hash = s.Codec.DecryptOrFallback(user.Password.hash)
+	}
+
+	return &user, nil
+}
+
+func (s pgxUserStore) Update(user *User) error {
+	passwordHash := user.Password.hash
+	if s.Codec != nil {
+		encrypted, err := s.Codec.Encrypt(passwordHash)
+		if err != nil {
+			return err
+		}
+		passwordHash = encrypted
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	version, err := s.Queries.UpdateUser(ctx, sqlc.UpdateUserParams{
+		Name:         user.Name,
+		Email:        user.Email,
+		PasswordHash: passwordHash,
+		Activated:    user.Activated,
+		ID:           user.ID,
+		Version:      int32(user.Version),
+	})
+	if err != nil {
+		switch {
+		case isPgxDuplicateEmail(err):
+			return ErrDuplicateEmail
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	user.Version = int(version)
+	return nil
+}
+
+func (s pgxUserStore) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	row, err := s.Queries.GetUserForToken(ctx, sqlc.GetUserForTokenParams{
+		Hash:   tokenHash[:],
+		Scope:  tokenScope,
+		Expiry: time.Now(),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	user := sqlcUserToUser(row)
+
+	if s.Codec != nil {
+		user.Password.hash = s.Codec.DecryptOrFallback(user.Password.hash)
+	}
+
+	return &user, nil
+}
+
+// sqlcUserToUser converts a sqlc-generated row to the internal User
+// representation shared with the lib/pq backend.
+func sqlcUserToUser(row sqlc.User) User {
+	return User{
+		ID:        row.ID,
+		CreatedAt: row.CreatedAt,
+		Name:      row.Name,
+		Email:     row.Email,
+		Password:  password{hash: row.PasswordHash},
+		Activated: row.Activated,
+		Version:   int(row.Version),
+	}
+}
+
+// isPgxDuplicateEmail reports whether err is the unique-violation a
+// pgx-backed insert/update returns for a clashing email, replacing the
+// brittle `err.Error() == "pq: duplicate key..."` string match the
+// lib/pq backend still uses.
+func isPgxDuplicateEmail(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgxErrCodeDuplicateEmail
+}
@@ -0,0 +1,136 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UserStore is satisfied by every user-lookup backend - the original
+// lib/pq-backed UserModel, the pgx/sqlc-backed pgxUserStore, and
+// MockUserModel - so handlers and tests can depend on the interface
+// instead of a concrete SQL driver. NewUserStore selects the concrete
+// backend.
+type UserStore interface {
+	Insert(user *User) error
+	GetByEmail(email string) (*User, error)
+	Update(user *User) error
+	GetForToken(tokenScope, tokenPlaintext string) (*User, error)
+	Authenticate(ctx context.Context, email, plaintextPassword, ip string) (*User, error)
+}
+
+var (
+	_ UserStore = UserModel{}
+	_ UserStore = MockUserModel{}
+	_ UserStore = pgxUserStore{}
+)
+
+// NewUserStore builds the UserStore for driver, which is expected to
+// be the value of a --db-driver=pq|pgx flag: "pq" (or "" for the
+// default) wraps db with NewUserModel, "pgx" wraps pool with
+// NewPgxUserStore. Callers pass whichever of db/pool matches driver
+// and leave the other nil.
+func NewUserStore(driver string, db *sql.DB, pool *pgxpool.Pool, hasher PasswordHasher, opts ...UserModelOption) (UserStore, error) {
+	switch driver {
+	case "", "pq":
+		return NewUserModel(db, hasher, opts...), nil
+	case "pgx":
+		return NewPgxUserStore(pool, hasher, opts...), nil
+	default:
+		return nil, fmt.Errorf("data: unknown --db-driver %q, want \"pq\" or \"pgx\"", driver)
+	}
+}
+
+// FailedLoginStore records and queries failed login attempts for the
+// sliding-window lockout enforced by authenticate. FailedLoginModel
+// (lib/pq) is the only implementation today, but it's kept behind an
+// interface so a pgx-backed UserStore can plug in its own storage
+// without duplicating the rate-limiting logic itself.
+type FailedLoginStore interface {
+	Count(ctx context.Context, email, ip string, window time.Duration) (int, error)
+	Record(ctx context.Context, email, ip string) error
+	Clear(ctx context.Context, email, ip string) error
+}
+
+// authenticate implements the sliding-window rate limit, enumeration-safe
+// error handling and transparent rehash-on-login shared by every
+// UserStore backend. getByEmail and update are the backend's own
+// GetByEmail/Update, so the driver-specific error translation
+// (pq string matching vs pgx.ErrNoRows/*pgconn.PgError) stays local to
+// each backend while this logic is written once.
+func authenticate(
+	ctx context.Context,
+	email, plaintextPassword, ip string,
+	getByEmail func(string) (*User, error),
+	update func(*User) error,
+	hasher PasswordHasher,
+	failedLogins FailedLoginStore,
+	limit AuthRateLimitConfig,
+) (*User, error) {
+	rateLimited := failedLogins != nil
+
+	if rateLimited {
+		attempts, err := failedLogins.Count(ctx, email, ip, limit.WindowLength)
+		if err != nil {
+			return nil, err
+		}
+		if attempts >= limit.RequestLimit {
+			return nil, ErrTooManyAttempts
+		}
+	}
+
+	user, err := getByEmail(email)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			if rateLimited {
+				if err := failedLogins.Record(ctx, email, ip); err != nil {
+					return nil, err
+				}
+			}
+			return nil, ErrInvalidCredentials
+		default:
+			return nil, err
+		}
+	}
+
+	match, err := user.Password.Matches(plaintextPassword)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		if rateLimited {
+			if err := failedLogins.Record(ctx, email, ip); err != nil {
+				return nil, err
+			}
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if rateLimited {
+		if err := failedLogins.Clear(ctx, email, ip); err != nil {
+			return nil, err
+		}
+	}
+
+	if hasher != nil && user.Password.needsRehash(hasher) {
+		if err := user.Password.Set(plaintextPassword, hasher); err != nil {
+			return nil, err
+		}
+		// The password already matched, so the login itself has
+		// succeeded; an edit conflict here just means a concurrent
+		// request touched the row first, and neither that nor any other
+		// write error is worth failing the login over. The hash gets
+		// another chance to upgrade on the user's next login.
+		if err := update(user); err != nil {
+			log.Printf("data: opportunistic password rehash for %q failed, leaving existing hash in place: %v", email, err)
+		}
+	}
+
+	return user, nil
+}
@@ -0,0 +1,193 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeFailedLoginStore is an in-memory FailedLoginStore double for
+// exercising authenticate()'s sliding-window lockout without a real
+// database.
+type fakeFailedLoginStore struct {
+	attempts map[string][]time.Time
+}
+
+func newFakeFailedLoginStore() *fakeFailedLoginStore {
+	return &fakeFailedLoginStore{attempts: make(map[string][]time.Time)}
+}
+
+func (f *fakeFailedLoginStore) key(email, ip string) string { return email + "|" + ip }
+
+func (f *fakeFailedLoginStore) Count(ctx context.Context, email, ip string, window time.Duration) (int, error) {
+	cutoff := time.Now().Add(-window)
+	count := 0
+	for _, at := range f.attempts[f.key(email, ip)] {
+		if at.After(cutoff) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (f *fakeFailedLoginStore) Record(ctx context.Context, email, ip string) error {
+	k := f.key(email, ip)
+	f.attempts[k] = append(f.attempts[k], time.Now())
+	return nil
+}
+
+func (f *fakeFailedLoginStore) Clear(ctx context.Context, email, ip string) error {
+	delete(f.attempts, f.key(email, ip))
+	return nil
+}
+
+func authenticateTestUser(t *testing.T, plaintext string, hasher PasswordHasher) *User {
+	t.Helper()
+	u := &User{ID: 1, Email: "test@test.com"}
+	if err := u.Password.Set(plaintext, hasher); err != nil {
+		t.Fatalf("Password.Set returned error: %v", err)
+	}
+	return u
+}
+
+func TestAuthenticateUnknownEmailAndBadPasswordBothInvalidCredentials(t *testing.T) {
+	hasher := BcryptHasher{Cost: 4}
+	user := authenticateTestUser(t, "pa55word", hasher)
+
+	getByEmail := func(email string) (*User, error) {
+		if email != user.Email {
+			return nil, ErrRecordNotFound
+		}
+		return user, nil
+	}
+	update := func(*User) error { return nil }
+
+	_, err := authenticate(context.Background(), "unknown@test.com", "pa55word", "1.2.3.4", getByEmail, update, hasher, nil, AuthRateLimitConfig{})
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("unknown email: got %v, want ErrInvalidCredentials", err)
+	}
+
+	_, err = authenticate(context.Background(), user.Email, "wrong-password", "1.2.3.4", getByEmail, update, hasher, nil, AuthRateLimitConfig{})
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("bad password: got %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestAuthenticateSlidingWindowLockout(t *testing.T) {
+	hasher := BcryptHasher{Cost: 4}
+	user := authenticateTestUser(t, "pa55word", hasher)
+	getByEmail := func(string) (*User, error) { return user, nil }
+	update := func(*User) error { return nil }
+	failedLogins := newFakeFailedLoginStore()
+	limit := AuthRateLimitConfig{RequestLimit: 3, WindowLength: time.Minute}
+
+	for i := 0; i < limit.RequestLimit; i++ {
+		_, err := authenticate(context.Background(), user.Email, "wrong-password", "1.2.3.4", getByEmail, update, hasher, failedLogins, limit)
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidCredentials", i, err)
+		}
+	}
+
+	_, err := authenticate(context.Background(), user.Email, "pa55word", "1.2.3.4", getByEmail, update, hasher, failedLogins, limit)
+	if !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("after exceeding the window: got %v, want ErrTooManyAttempts, even with the correct password", err)
+	}
+
+	// A different IP has its own window and isn't locked out.
+	if _, err := authenticate(context.Background(), user.Email, "pa55word", "5.6.7.8", getByEmail, update, hasher, failedLogins, limit); err != nil {
+		t.Errorf("different ip: got unexpected error %v", err)
+	}
+}
+
+func TestAuthenticateSuccessClearsFailedLogins(t *testing.T) {
+	hasher := BcryptHasher{Cost: 4}
+	user := authenticateTestUser(t, "pa55word", hasher)
+	getByEmail := func(string) (*User, error) { return user, nil }
+	update := func(*User) error { return nil }
+	failedLogins := newFakeFailedLoginStore()
+	limit := AuthRateLimitConfig{RequestLimit: 2, WindowLength: time.Minute}
+
+	if _, err := authenticate(context.Background(), user.Email, "wrong-password", "1.2.3.4", getByEmail, update, hasher, failedLogins, limit); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("got %v, want ErrInvalidCredentials", err)
+	}
+
+	if _, err := authenticate(context.Background(), user.Email, "pa55word", "1.2.3.4", getByEmail, update, hasher, failedLogins, limit); err != nil {
+		t.Fatalf("successful login returned error: %v", err)
+	}
+
+	count, err := failedLogins.Count(context.Background(), user.Email, "1.2.3.4", limit.WindowLength)
+	if err != nil {
+		t.Fatalf("Count returned error: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("expected the failed-login count to be cleared after a successful login, got %d", count)
+	}
+}
+
+func TestAuthenticateRehashesWeakHashOnLogin(t *testing.T) {
+	weak := BcryptHasher{Cost: 4}
+	active := BcryptHasher{Cost: 6}
+	user := authenticateTestUser(t, "pa55word", weak)
+	originalHash := string(user.Password.hash)
+
+	var updated *User
+	getByEmail := func(string) (*User, error) { return user, nil }
+	update := func(u *User) error { updated = u; return nil }
+
+	got, err := authenticate(context.Background(), user.Email, "pa55word", "1.2.3.4", getByEmail, update, active, nil, AuthRateLimitConfig{})
+	if err != nil {
+		t.Fatalf("authenticate returned error: %v", err)
+	}
+	if updated == nil {
+		t.Fatal("expected Update to be called to write back the rehashed password")
+	}
+	if string(got.Password.hash) == originalHash {
+		t.Error("expected the password hash to change after a rehash-triggering login")
+	}
+	if active.NeedsRehash(got.Password.hash) {
+		t.Error("the rehashed password should no longer need rehashing under the active hasher")
+	}
+}
+
+func TestAuthenticateRehashWriteFailureIsBestEffort(t *testing.T) {
+	weak := BcryptHasher{Cost: 4}
+	active := BcryptHasher{Cost: 6}
+	user := authenticateTestUser(t, "pa55word", weak)
+
+	getByEmail := func(string) (*User, error) { return user, nil }
+	update := func(*User) error { return ErrEditConflict }
+
+	got, err := authenticate(context.Background(), user.Email, "pa55word", "1.2.3.4", getByEmail, update, active, nil, AuthRateLimitConfig{})
+	if err != nil {
+		t.Fatalf("a correct password should succeed even if the opportunistic rehash write fails, got error: %v", err)
+	}
+	if got == nil {
+		t.Fatal("expected a user to be returned")
+	}
+}
+
+func TestMockUserModelAuthenticate(t *testing.T) {
+	m := MockUserModel{}
+	ctx := context.Background()
+
+	if _, err := m.Authenticate(ctx, "locked@test.com", "whatever", "1.2.3.4"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("locked sentinel: got %v, want ErrTooManyAttempts", err)
+	}
+
+	if _, err := m.Authenticate(ctx, "test@test.com", "totally-wrong-password", "1.2.3.4"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("wrong password: got %v, want ErrInvalidCredentials", err)
+	}
+
+	user, err := m.Authenticate(ctx, "test@test.com", "TestPassword", "1.2.3.4")
+	if err != nil {
+		t.Fatalf("correct password returned error: %v", err)
+	}
+	if user.Email != "test@test.com" {
+		t.Errorf("got user %q, want test@test.com", user.Email)
+	}
+
+	if _, err := m.Authenticate(ctx, "notfound@test.com", "whatever", "1.2.3.4"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("unknown email: got %v, want ErrInvalidCredentials", err)
+	}
+}
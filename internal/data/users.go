@@ -8,7 +8,7 @@ import (
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
-	"greenlight.bcc/internal/validator"
+	"greenlight.bcc/internal/data/dto"
 )
 
 var (
@@ -31,31 +31,49 @@ func (u *User) IsAnonymous() bool {
 	return u == AnonymousUser
 }
 
-func ValidateEmail(v *validator.Validator, email string) {
-	v.Check(email != "", "email", "must be provided")
-	v.Check(validator.Matches(email, validator.EmailRX), "email", "must be a valid email address")
-}
-
-func ValidatePasswordPlaintext(v *validator.Validator, password string) {
-	v.Check(password != "", "password", "must be provided")
-	v.Check(len(password) >= 8, "password", "must be at least 8 bytes long")
-	v.Check(len(password) <= 72, "password", "must not be more than 72 bytes long")
+// NewUserFromCreateDTO builds the internal User representation of a
+// registration request, hashing the plaintext password with hasher so
+// callers never touch the password type directly. d is assumed to have
+// already passed dto.ValidateUserCreate.
+func NewUserFromCreateDTO(d dto.UserCreateDTO, hasher PasswordHasher) (*User, error) {
+	user := &User{
+		Name:  d.Name,
+		Email: d.Email,
+	}
+	if err := user.Password.Set(d.Password, hasher); err != nil {
+		return nil, err
+	}
+	return user, nil
 }
 
-func ValidateUser(v *validator.Validator, user *User) {
-	v.Check(user.Name != "", "name", "must be provided")
-	v.Check(len(user.Name) <= 500, "name", "must not be more than 500 bytes long")
-
-	ValidateEmail(v, user.Email)
-
-	if user.Password.plaintext != nil {
-		ValidatePasswordPlaintext(v, *user.Password.plaintext)
+// ApplyUpdateDTO copies the supplied fields of a UserUpdateDTO onto u,
+// leaving any field that wasn't supplied untouched. d is assumed to
+// have already passed dto.ValidateUserUpdate.
+func (u *User) ApplyUpdateDTO(d dto.UserUpdateDTO, hasher PasswordHasher) error {
+	if d.Name != nil {
+		u.Name = *d.Name
 	}
-
-	if user.Password.hash == nil {
-		panic("missing password hash for user")
+	if d.Email != nil {
+		u.Email = *d.Email
 	}
+	if d.Password != nil {
+		if err := u.Password.Set(*d.Password, hasher); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// ToResponseDTO converts u to the shape returned to API clients, which
+// never includes the password hash.
+func (u *User) ToResponseDTO() dto.UserResponseDTO {
+	return dto.UserResponseDTO{
+		ID:        u.ID,
+		CreatedAt: u.CreatedAt,
+		Name:      u.Name,
+		Email:     u.Email,
+		Activated: u.Activated,
+	}
 }
 
 type password struct {
@@ -63,8 +81,11 @@ type password struct {
 	hash      []byte
 }
 
-func (p *password) Set(plaintextPassword string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), 12)
+// Set hashes plaintextPassword with hasher and stores the result. The
+// hash carries its own algorithm identifier, so a later Matches call
+// doesn't need to know which hasher produced it.
+func (p *password) Set(plaintextPassword string, hasher PasswordHasher) error {
+	hash, err := hasher.Hash(plaintextPassword)
 	if err != nil {
 		return err
 	}
@@ -74,28 +95,109 @@ func (p *password) Set(plaintextPassword string) error {
 }
 
 func (p *password) Matches(plaintextPassword string) (bool, error) {
-	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
+	hasher, err := hasherForHash(p.hash)
 	if err != nil {
-		switch {
-		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
-			return false, nil
-		default:
-			return false, err
-		}
+		return false, err
 	}
-	return true, nil
+	return hasher.Matches(p.hash, plaintextPassword)
+}
+
+// needsRehash reports whether this password's hash falls below the
+// parameters the active hasher is configured with.
+func (p *password) needsRehash(activeHasher PasswordHasher) bool {
+	return activeHasher.NeedsRehash(p.hash)
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB     *sql.DB
+	Hasher PasswordHasher
+	// Codec encrypts/decrypts password_hash at rest. It is nil on a
+	// database that hasn't been through cmd/migrate-encrypt-passwords
+	// yet, in which case Insert/Update/GetByEmail/GetForToken fall back
+	// to storing and reading the hash unencrypted.
+	Codec *EncryptedPasswordCodec
+	// FailedLogins and AuthLimit back the sliding-window lockout in
+	// Authenticate. FailedLogins is nil when rate limiting isn't
+	// configured, in which case Authenticate skips it entirely.
+	FailedLogins FailedLoginStore
+	AuthLimit    AuthRateLimitConfig
+}
+
+// UserModelOption configures optional UserModel dependencies. Password
+// hashing is the only one every deployment needs; encryption at rest
+// and login rate limiting are opt-in, so they're threaded through
+// options rather than required constructor parameters.
+type UserModelOption func(*UserModel)
+
+// WithPasswordEncryption enables at-rest encryption of password_hash
+// using codec, once the database has been through
+// cmd/migrate-encrypt-passwords.
+func WithPasswordEncryption(codec *EncryptedPasswordCodec) UserModelOption {
+	return func(m *UserModel) { m.Codec = codec }
+}
+
+// WithAuthRateLimit enables the sliding-window failed-login lockout
+// enforced by Authenticate, backed by failedLogins and configured by
+// cfg (the AuthRequestLimit / AuthWindowLength config options).
+func WithAuthRateLimit(failedLogins FailedLoginStore, cfg AuthRateLimitConfig) UserModelOption {
+	return func(m *UserModel) {
+		m.FailedLogins = failedLogins
+		m.AuthLimit = cfg
+	}
+}
+
+// NewUserModel wires db and the active password hasher (built from
+// config via NewPasswordHasher) into a UserModel, applying any opts on
+// top.
+func NewUserModel(db *sql.DB, hasher PasswordHasher, opts ...UserModelOption) UserModel {
+	m := UserModel{DB: db, Hasher: hasher}
+	for _, opt := range opts {
+		opt(&m)
+	}
+	return m
+}
+
+var (
+	// ErrInvalidCredentials is returned by Authenticate for both an
+	// unknown email and a bad password, so callers can't use it to
+	// enumerate users.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrTooManyAttempts is returned by Authenticate once the sliding
+	// window of failed attempts for an (email, ip) pair has been
+	// exceeded, regardless of whether the supplied password is correct.
+	ErrTooManyAttempts = errors.New("too many failed login attempts")
+)
+
+// Authenticate looks up the user by email and checks plaintextPassword
+// against their stored hash, returning ErrInvalidCredentials if either
+// the email is unknown or the password doesn't match - this prevents
+// user enumeration. When rate limiting is configured (WithAuthRateLimit)
+// it first checks the sliding window of failed attempts for (email, ip)
+// and returns ErrTooManyAttempts if it's been exceeded; a successful
+// login clears the window. On a successful match against a hash that's
+// weaker than the active hasher's parameters (lower bcrypt cost, or a
+// different algorithm entirely), it transparently re-hashes the
+// password and writes it back, so users are migrated to the active
+// scheme without a forced password reset.
+func (m UserModel) Authenticate(ctx context.Context, email, plaintextPassword, ip string) (*User, error) {
+	return authenticate(ctx, email, plaintextPassword, ip, m.GetByEmail, m.Update, m.Hasher, m.FailedLogins, m.AuthLimit)
 }
 
 func (m UserModel) Insert(user *User) error {
+	passwordHash := user.Password.hash
+	if m.Codec != nil {
+		encrypted, err := m.Codec.Encrypt(passwordHash)
+		if err != nil {
+			return err
+		}
+		passwordHash = encrypted
+	}
+
 	query := `
 	INSERT INTO users (name, email, password_hash, activated)
 	VALUES ($1, $2, $3, $4)
 	RETURNING id, created_at, version`
-	args := []any{user.Name, user.Email, user.Password.hash, user.Activated}
+	args := []any{user.Name, user.Email, passwordHash, user.Activated}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
@@ -137,10 +239,24 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 			return nil, err
 		}
 	}
+
+	if m.Codec != nil {
+		user.Password.hash = m.Codec.DecryptOrFallback(user.Password.hash)
+	}
+
 	return &user, nil
 }
 
 func (m UserModel) Update(user *User) error {
+	passwordHash := user.Password.hash
+	if m.Codec != nil {
+		encrypted, err := m.Codec.Encrypt(passwordHash)
+		if err != nil {
+			return err
+		}
+		passwordHash = encrypted
+	}
+
 	query := `
 	UPDATE users
 	SET name = $1, email = $2, password_hash = $3, activated = $4, version = version + 1
@@ -149,7 +265,7 @@ func (m UserModel) Update(user *User) error {
 	args := []any{
 		user.Name,
 		user.Email,
-		user.Password.hash,
+		passwordHash,
 		user.Activated,
 		user.ID,
 		user.Version,
@@ -205,6 +321,10 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 		}
 	}
 
+	if m.Codec != nil {
+		user.Password.hash = m.Codec.DecryptOrFallback(user.Password.hash)
+	}
+
 	return &user, nil
 }
 
@@ -212,6 +332,35 @@ type MockUserModel struct {
 	DB *sql.DB
 }
 
+// mockUserFixtures maps well-known sentinel emails to the registration
+// DTO they should behave as, replacing the old "switch on email[4]"
+// hack with fixtures a reader can tell apart by name.
+var mockUserFixtures = map[string]dto.UserCreateDTO{
+	"test@test.com":          {Name: "Test", Email: "test@test.com", Password: "TestPassword"},
+	"nohash@test.com":        {Name: "Test", Email: "nohash@test.com", Password: "TestPassword"},
+	"mismatch@test.com":      {Name: "Test", Email: "mismatch@test.com", Password: "TestPassword"},
+	"secondaccount@test.com": {Name: "Test", Email: "secondaccount@test.com", Password: "TestPassword"},
+}
+
+// mockUserFromFixture hashes create.Password with a cheap bcrypt cost
+// (this is a test double, speed matters more than strength) and builds
+// the resulting User as if it had just been read back from the store.
+func mockUserFromFixture(id int64, create dto.UserCreateDTO) (*User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(create.Password), 10)
+	if err != nil {
+		return nil, err
+	}
+	return &User{
+		ID:        id,
+		CreatedAt: time.Now(),
+		Name:      create.Name,
+		Email:     create.Email,
+		Password:  password{plaintext: &create.Password, hash: hash},
+		Activated: true,
+		Version:   1,
+	}, nil
+}
+
 func (m MockUserModel) Insert(user *User) error {
 	if user.Name == "invalid" {
 		return errors.New("invalid name")
@@ -229,58 +378,43 @@ func (m MockUserModel) Insert(user *User) error {
 }
 
 func (m MockUserModel) GetByEmail(email string) (*User, error) {
-	passwd := "TestPassword"
-	sha, _ := bcrypt.GenerateFromPassword([]byte(passwd), 10)
-
-	switch email[4] {
-	case '1':
+	switch email {
+	case "notfound@test.com":
 		return nil, ErrRecordNotFound
-	case '2':
+	case "dberror@test.com":
 		return nil, errors.New("database has fallen")
-	case '3':
+	case "nohash@test.com":
+		create := mockUserFixtures[email]
 		return &User{
 			ID:        1,
 			CreatedAt: time.Now(),
-			Name:      "Test",
-			Email:     "test@test.com",
-			Password:  password{plaintext: &passwd, hash: []byte{}},
+			Name:      create.Name,
+			Email:     create.Email,
+			Password:  password{plaintext: &create.Password, hash: []byte{}},
 			Activated: true,
 			Version:   1,
 		}, nil
-	case '4':
-		invalidPassword := "invalid_password"
-		sha2, _ := bcrypt.GenerateFromPassword([]byte(passwd), 10)
+	case "mismatch@test.com":
+		create := mockUserFixtures[email]
+		stored := "a-completely-different-password"
+		hash, _ := bcrypt.GenerateFromPassword([]byte(stored), 10)
 		return &User{
 			ID:        1,
 			CreatedAt: time.Now(),
-			Name:      "Test",
-			Email:     "test@test.com",
-			Password:  password{plaintext: &invalidPassword, hash: sha2},
-			Activated: true,
-			Version:   1,
-		}, nil
-	case '5':
-		return &User{
-			ID:        2,
-			CreatedAt: time.Now(),
-			Name:      "Test",
-			Email:     "test@test.com",
-			Password:  password{plaintext: &passwd, hash: sha},
+			Name:      create.Name,
+			Email:     create.Email,
+			Password:  password{plaintext: &stored, hash: hash},
 			Activated: true,
 			Version:   1,
 		}, nil
-
+	case "secondaccount@test.com":
+		return mockUserFromFixture(2, mockUserFixtures[email])
 	}
 
-	return &User{
-		ID:        1,
-		CreatedAt: time.Now(),
-		Name:      "Test",
-		Email:     "test@test.com",
-		Password:  password{plaintext: &passwd, hash: sha},
-		Activated: true,
-		Version:   1,
-	}, nil
+	if create, ok := mockUserFixtures[email]; ok {
+		return mockUserFromFixture(1, create)
+	}
+	return mockUserFromFixture(1, mockUserFixtures["test@test.com"])
 }
 
 func (m MockUserModel) Update(user *User) error {
@@ -294,56 +428,32 @@ func (m MockUserModel) Update(user *User) error {
 	return nil
 }
 
-func (m MockUserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
-
-	passwd := "testPassword"
-	sha, _ := bcrypt.GenerateFromPassword([]byte(passwd), 10)
+// Authenticate shares the real authenticate() logic (password check,
+// enumeration-safe errors) with UserModel and pgxUserStore, so a test
+// asserting a wrong password is rejected exercises the same code path
+// it would against a real backend. "locked@test.com" is a sentinel on
+// top of that shared logic, letting handler tests simulate the lockout
+// path without a real FailedLoginModel.
+func (m MockUserModel) Authenticate(ctx context.Context, email, plaintextPassword, ip string) (*User, error) {
+	if email == "locked@test.com" {
+		return nil, ErrTooManyAttempts
+	}
+	return authenticate(ctx, email, plaintextPassword, ip, m.GetByEmail, m.Update, nil, nil, AuthRateLimitConfig{})
+}
 
-	switch tokenPlaintext[len(tokenPlaintext)-1] {
-	case '1':
+func (m MockUserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+	switch tokenPlaintext {
+	case "notfound-token":
 		return nil, ErrRecordNotFound
-	case '2':
+	case "dberror-token":
 		return nil, errors.New("some err")
-	case '3':
-		return &User{
-			ID:        1,
-			CreatedAt: time.Now(),
-			Name:      "Test",
-			Email:     "testConflict@test.com",
-			Password:  password{plaintext: &passwd, hash: sha},
-			Activated: true,
-			Version:   1,
-		}, nil
-	case '4':
-		return &User{
-			ID:        1,
-			CreatedAt: time.Now(),
-			Name:      "Test",
-			Email:     "testErr@test.com",
-			Password:  password{plaintext: &passwd, hash: sha},
-			Activated: true,
-			Version:   1,
-		}, nil
-	case '5':
-		return &User{
-			ID:        2,
-			CreatedAt: time.Now(),
-			Name:      "Test",
-			Email:     "test@test.com",
-			Password:  password{plaintext: &passwd, hash: sha},
-			Activated: true,
-			Version:   1,
-		}, nil
-
+	case "conflict-token":
+		return mockUserFromFixture(1, dto.UserCreateDTO{Name: "Test", Email: "testConflict@test.com", Password: "testPassword"})
+	case "updateerror-token":
+		return mockUserFromFixture(1, dto.UserCreateDTO{Name: "Test", Email: "testErr@test.com", Password: "testPassword"})
+	case "secondaccount-token":
+		return mockUserFromFixture(2, dto.UserCreateDTO{Name: "Test", Email: "test@test.com", Password: "testPassword"})
 	}
 
-	return &User{
-		ID:        1,
-		CreatedAt: time.Now(),
-		Name:      "Test",
-		Email:     "test@test.com",
-		Password:  password{plaintext: &passwd, hash: sha},
-		Activated: true,
-		Version:   1,
-	}, nil
-}
\ No newline at end of file
+	return mockUserFromFixture(1, dto.UserCreateDTO{Name: "Test", Email: "test@test.com", Password: "testPassword"})
+}